@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// templateFuncs are made available to every template in the set loaded by
+// loadTemplates, for formatting directory-listing fields.
+var templateFuncs = template.FuncMap{
+	"humanSize": humanSize,
+	"formatTime": func(t time.Time) string {
+		return t.Format("2006-01-02 15:04")
+	},
+}
+
+// browsePrefix is the URL prefix the directory-browsing handler is
+// mounted under. Anything under it maps to a path inside filesDir.
+const browsePrefix = "/browse/"
+
+// browseEntry describes one file or subdirectory for the directory
+// listing template.
+type browseEntry struct {
+	Name    string
+	Href    string
+	IsDir   bool
+	Size    int64
+	ModTime time.Time
+	Icon    string
+}
+
+// breadcrumb is one link in the breadcrumb trail above a directory
+// listing.
+type breadcrumb struct {
+	Name string
+	Href string
+}
+
+// browseData is the data handed to templates/browse.html.
+type browseData struct {
+	Path        string
+	Breadcrumbs []breadcrumb
+	ParentHref  string
+	Entries     []browseEntry
+	Sort        string
+	Order       string
+}
+
+// browseHandler serves ./static/files as a browsable tree: a GET on a
+// file streams it back, a GET on a directory renders an HTML listing
+// sorted by the sort/order query parameters.
+func browseHandler(w http.ResponseWriter, r *http.Request) {
+	relPath := path.Clean("/" + strings.TrimPrefix(r.URL.Path, browsePrefix))[1:]
+
+	fullPath := filepath.Join(filesDir, filepath.FromSlash(relPath))
+	if !isWithinDir(filesDir, fullPath) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	if !info.IsDir() {
+		http.ServeFile(w, r, fullPath)
+		return
+	}
+
+	dirEntries, err := os.ReadDir(fullPath)
+	if err != nil {
+		http.Error(w, "Unable to read directory", http.StatusInternalServerError)
+		return
+	}
+
+	sortBy := r.URL.Query().Get("sort")
+	if sortBy != "name" && sortBy != "size" && sortBy != "date" {
+		sortBy = "name"
+	}
+	order := r.URL.Query().Get("order")
+	if order != "asc" && order != "desc" {
+		order = "asc"
+	}
+
+	entries := make([]browseEntry, 0, len(dirEntries))
+	for _, de := range dirEntries {
+		fi, err := de.Info()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, browseEntry{
+			Name:    fi.Name(),
+			Href:    browseHref(relPath, fi.Name(), fi.IsDir()),
+			IsDir:   fi.IsDir(),
+			Size:    fi.Size(),
+			ModTime: fi.ModTime(),
+			Icon:    browseIcon(fi),
+		})
+	}
+	sortBrowseEntries(entries, sortBy, order)
+
+	data := browseData{
+		Path:        "/" + relPath,
+		Breadcrumbs: browseBreadcrumbs(relPath),
+		Entries:     entries,
+		Sort:        sortBy,
+		Order:       order,
+	}
+	if relPath != "" {
+		if parent := path.Dir(relPath); parent == "." {
+			data.ParentHref = browsePrefix
+		} else {
+			data.ParentHref = browsePrefix + parent + "/"
+		}
+	}
+
+	tmpl := templates
+	if assetsDir != "" {
+		var err error
+		tmpl, err = loadTemplates()
+		if err != nil {
+			http.Error(w, "Unable to load templates", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tmpl.ExecuteTemplate(w, "browse.html", data); err != nil {
+		http.Error(w, "Unable to render template", http.StatusInternalServerError)
+	}
+}
+
+// browseHref builds the URL, relative to browsePrefix, for an entry named
+// name inside dir, following the same per-segment escaping that
+// http.FileServer's directory listing uses.
+func browseHref(dir, name string, isDir bool) string {
+	href := browsePrefix + path.Join(dir, url.PathEscape(name))
+	if isDir {
+		href += "/"
+	}
+	return href
+}
+
+// browseBreadcrumbs turns a "/"-separated relative path into a list of
+// breadcrumb links, one per path segment.
+func browseBreadcrumbs(relPath string) []breadcrumb {
+	crumbs := []breadcrumb{{Name: "files", Href: browsePrefix}}
+	if relPath == "" {
+		return crumbs
+	}
+
+	var built string
+	for _, segment := range strings.Split(relPath, "/") {
+		built = path.Join(built, url.PathEscape(segment))
+		crumbs = append(crumbs, breadcrumb{
+			Name: segment,
+			Href: browsePrefix + built + "/",
+		})
+	}
+	return crumbs
+}
+
+// browseIcon maps a directory entry to a small icon based on its
+// mimetype, mirroring the kind of hinting http.FileServer's dirList
+// leaves to the browser's default icon set.
+func browseIcon(fi os.FileInfo) string {
+	if fi.IsDir() {
+		return "📁"
+	}
+	switch strings.ToLower(filepath.Ext(fi.Name())) {
+	case ".png", ".jpg", ".jpeg", ".gif":
+		return "🖼️"
+	case ".pdf":
+		return "📕"
+	case ".txt", ".md", ".csv", ".json":
+		return "📄"
+	default:
+		return "📦"
+	}
+}
+
+// sortBrowseEntries sorts entries in place by the requested key and
+// order, listing directories before files within that order.
+func sortBrowseEntries(entries []browseEntry, sortBy, order string) {
+	less := func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.IsDir != b.IsDir {
+			return a.IsDir // directories first
+		}
+		switch sortBy {
+		case "size":
+			return a.Size < b.Size
+		case "date":
+			return a.ModTime.Before(b.ModTime)
+		default:
+			return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if order == "desc" {
+			return less(j, i)
+		}
+		return less(i, j)
+	})
+}
+
+// isWithinDir reports whether target is dir itself or a descendant of it,
+// guarding browseHandler against "../" segments escaping filesDir.
+func isWithinDir(dir, target string) bool {
+	rel, err := filepath.Rel(dir, target)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (!strings.HasPrefix(rel, "..") && !filepath.IsAbs(rel))
+}
+
+// humanSize renders a byte count the way directory listings traditionally
+// do, e.g. "1.5 KB".
+func humanSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// atomicWriteFile writes data to path by writing it to a temp file in the
+// same directory, fsyncing it, then renaming it over path, so a reader
+// never observes a partially written file.
+func atomicWriteFile(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // no-op once the rename below succeeds
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// replaceFileHandler handles POST /replace, overwriting the selected
+// file's entire contents with the posted text.
+func replaceFileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filename, err := sanitizeFilename(r.FormValue("file"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filePath := filepath.Join(filesDir, filename)
+
+	release := fileLocks.acquire(filePath)
+	defer release()
+
+	if err := atomicWriteFile(filePath, []byte(r.FormValue("text")+"\n"), 0644); err != nil {
+		http.Error(w, "Error replacing file", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// truncateFileHandler handles POST /truncate, emptying the selected file
+// without removing it.
+func truncateFileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filename, err := sanitizeFilename(r.FormValue("file"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filePath := filepath.Join(filesDir, filename)
+
+	release := fileLocks.acquire(filePath)
+	defer release()
+
+	if err := atomicWriteFile(filePath, nil, 0644); err != nil {
+		http.Error(w, "Error truncating file", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// deleteFileHandler handles DELETE /file/{name}, removing the named file
+// from filesDir.
+func deleteFileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filename, err := sanitizeFilename(strings.TrimPrefix(r.URL.Path, "/file/"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filePath := filepath.Join(filesDir, filename)
+
+	release := fileLocks.acquire(filePath)
+	defer release()
+
+	if err := os.Remove(filePath); err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error deleting file", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
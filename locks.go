@@ -0,0 +1,52 @@
+package main
+
+import "sync"
+
+// fileLock is a reference-counted mutex for a single file path.
+type fileLock struct {
+	mu       sync.Mutex
+	refCount int
+}
+
+// fileLockManager hands out a *sync.Mutex per file path so that the
+// read-modify-write cycles behind the mutating file handlers don't race
+// each other. Entries are reference-counted and evicted once nothing is
+// using them, so the map doesn't grow without bound.
+type fileLockManager struct {
+	mu    sync.Mutex
+	locks map[string]*fileLock
+}
+
+func newFileLockManager() *fileLockManager {
+	return &fileLockManager{locks: make(map[string]*fileLock)}
+}
+
+// acquire locks the mutex for path, creating it on first use, and returns
+// a release func that must be called exactly once to unlock it and evict
+// the entry if no one else is waiting on it.
+func (m *fileLockManager) acquire(path string) (release func()) {
+	m.mu.Lock()
+	fl, ok := m.locks[path]
+	if !ok {
+		fl = &fileLock{}
+		m.locks[path] = fl
+	}
+	fl.refCount++
+	m.mu.Unlock()
+
+	fl.mu.Lock()
+
+	return func() {
+		fl.mu.Unlock()
+
+		m.mu.Lock()
+		fl.refCount--
+		if fl.refCount == 0 {
+			delete(m.locks, path)
+		}
+		m.mu.Unlock()
+	}
+}
+
+// fileLocks guards every handler that mutates a file under filesDir.
+var fileLocks = newFileLockManager()
@@ -1,15 +1,118 @@
 package main
 
 import (
+	"embed"
+	"flag"
 	"fmt"
 	"html/template"
+	"io"
+	"io/fs"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
+// filesDir is where uploaded files are stored and where the combo box on
+// the index page reads its list of files from.
+const filesDir = "./static/files"
+
+// defaultTemplates and defaultStatic compile the app's own templates and
+// static assets into the binary so it can run as a single executable with
+// no files alongside it. They do not cover filesDir: uploaded files always
+// live on disk, never in the binary.
+//
+//go:embed templates
+var defaultTemplates embed.FS
+
+//go:embed static/style.css
+var defaultStatic embed.FS
+
+// assetsDir, when set via -assets-dir, bypasses the embedded assets above
+// and reads the templates and static files straight off disk on every
+// request, so edits show up without rebuilding the binary.
+var assetsDir string
+
+// templates holds the compiled-in template set, parsed once at startup. It
+// is left nil when -assets-dir is set, in which case handlers call
+// loadTemplates directly so edits to the tree on disk take effect
+// immediately.
+var templates *template.Template
+
+// loadTemplates walks a "templates" directory tree - the one embedded in
+// the binary, or the one under assetsDir when set - and registers every
+// .html file it finds under a name derived from its path relative to the
+// tree root (e.g. "includes/header.html" rather than just "header.html").
+// This avoids the well-known collision that ParseFiles/ParseGlob run into
+// when two templates share a basename, and lets templates reference each
+// other by that same path via {{template "includes/header.html"}}.
+func loadTemplates() (*template.Template, error) {
+	var root fs.FS
+	if assetsDir != "" {
+		root = os.DirFS(filepath.Join(assetsDir, "templates"))
+	} else {
+		sub, err := fs.Sub(defaultTemplates, "templates")
+		if err != nil {
+			return nil, err
+		}
+		root = sub
+	}
+
+	set := template.New("templates").Funcs(templateFuncs)
+	err := fs.WalkDir(root, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".html" {
+			return nil
+		}
+		content, err := fs.ReadFile(root, path)
+		if err != nil {
+			return err
+		}
+		_, err = set.New(path).Parse(string(content))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// staticFileSystem returns the http.FileSystem used to serve /static/,
+// backed by the embedded assets unless assetsDir overrides it.
+func staticFileSystem() http.FileSystem {
+	if assetsDir != "" {
+		return http.Dir(filepath.Join(assetsDir, "static"))
+	}
+	sub, err := fs.Sub(defaultStatic, "static")
+	if err != nil {
+		log.Fatal(err) // The embed directive above guarantees this subtree exists
+	}
+	return http.FS(sub)
+}
+
+// maxUploadMemory bounds how much of a multipart upload ParseMultipartForm
+// will buffer in memory before spilling to temporary files on disk.
+const maxUploadMemory = 10 << 20 // 10 MB
+
+// maxUploadSize is the largest file we accept via /upload.
+const maxUploadSize = 50 << 20 // 50 MB
+
+// allowedUploadTypes is the MIME-type allowlist enforced on /upload.
+var allowedUploadTypes = map[string]bool{
+	"text/plain":       true,
+	"text/csv":         true,
+	"text/markdown":    true,
+	"application/json": true,
+	"application/pdf":  true,
+	"image/png":        true,
+	"image/jpeg":       true,
+	"image/gif":        true,
+}
+
 // This Go application serves a simple web interface that allows users to select a text file
 // from a specified directory and append text to it. It utilizes the built-in net/http package
 // to create an HTTP server, handling both the rendering of an HTML page with a combo box for
@@ -22,8 +125,28 @@ type TemplateData struct {
 }
 
 func main() {
-	// Serve static files (CSS) from the static directory
-	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("./static"))))
+	flag.StringVar(&assetsDir, "assets-dir", "", "serve templates and static assets from this directory instead of the ones compiled into the binary (for development)")
+	flag.StringVar(&logFile, "log-file", "", "write access logs to this file instead of stdout")
+	flag.StringVar(&logFormat, "log-format", "clf", "access log format: clf or json")
+	flag.Parse()
+
+	if err := openAccessLog(); err != nil {
+		log.Fatal(err)
+	}
+
+	if assetsDir == "" {
+		var err error
+		templates, err = loadTemplates()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Uploaded files always live on disk, regardless of -assets-dir.
+	http.Handle("/static/files/", http.StripPrefix("/static/files/", http.FileServer(http.Dir(filesDir))))
+
+	// Serve the app's own static assets (e.g. CSS), embedded by default.
+	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(staticFileSystem())))
 
 	// Handle the root route, rendering the file selector interface
 	http.HandleFunc("/", fileSelectorHandler)
@@ -31,28 +154,47 @@ func main() {
 	// Handle form submissions for appending text to the selected file
 	http.HandleFunc("/append", appendTextHandler)
 
+	// Handle multipart uploads of new files into the files directory
+	http.HandleFunc("/upload", uploadFileHandler)
+
+	// Handle whole-file content operations, all serialized through fileLocks
+	http.HandleFunc("/replace", replaceFileHandler)
+	http.HandleFunc("/truncate", truncateFileHandler)
+	http.HandleFunc("/file/", deleteFileHandler)
+
+	// Browse the files directory as a lightweight file-share portal
+	http.HandleFunc(browsePrefix, browseHandler)
+
 	// Start the HTTP server on port 8080
 	fmt.Println("Server starting at http://localhost:8080")
-	log.Fatal(http.ListenAndServe(":8080", nil)) // Blocks until the server stops
+	log.Fatal(http.ListenAndServe(":8080", loggingMiddleware(http.DefaultServeMux))) // Blocks until the server stops
 }
 
 // fileSelectorHandler renders the HTML page with the combo box
 func fileSelectorHandler(w http.ResponseWriter, r *http.Request) {
 	// Get list of files from the specified directory
-	files := getFilesFromDirectory("./static/files") // Calls function to retrieve file names
+	files := getFilesFromDirectory(filesDir) // Calls function to retrieve file names
 
 	// Prepare template data
 	data := TemplateData{
 		Files: files, // Assign the list of files to the TemplateData struct
 	}
 
-	// Parse and execute the HTML template
-	tmpl, err := template.ParseFiles("index.html") // Parse the HTML file
-	if err != nil {
-		http.Error(w, "Unable to load template", http.StatusInternalServerError) // Handle error if template fails
-		return
+	// Use the compiled-in template set, or reparse from -assets-dir on
+	// every request when it's set.
+	tmpl := templates
+	if assetsDir != "" {
+		var err error
+		tmpl, err = loadTemplates()
+		if err != nil {
+			http.Error(w, "Unable to load templates", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tmpl.ExecuteTemplate(w, "index.html", data); err != nil {
+		http.Error(w, "Unable to render template", http.StatusInternalServerError)
 	}
-	tmpl.Execute(w, data) // Execute the template with the provided data
 }
 
 // appendTextHandler handles the form submission and appends text to the selected file
@@ -62,8 +204,18 @@ func appendTextHandler(w http.ResponseWriter, r *http.Request) {
 		selectedFile := r.FormValue("file") // Get the selected file from the dropdown
 		textToAppend := r.FormValue("text") // Get the text to append from the input field
 
+		filename, err := sanitizeFilename(selectedFile)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		// Open and append text to the selected file
-		filePath := filepath.Join("./static/files", selectedFile)         // Create the full path to the selected file
+		filePath := filepath.Join(filesDir, filename) // Create the full path to the selected file
+
+		release := fileLocks.acquire(filePath) // Serialize access to this file across handlers
+		defer release()
+
 		file, err := os.OpenFile(filePath, os.O_APPEND|os.O_WRONLY, 0644) // Open the file in append mode
 		if err != nil {
 			http.Error(w, "Error opening file", http.StatusInternalServerError) // Handle error if file can't be opened
@@ -76,12 +228,119 @@ func appendTextHandler(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Error writing to file", http.StatusInternalServerError) // Handle error if write fails
 			return
 		}
+		if err := file.Sync(); err != nil { // Flush to disk before the next handler can observe this file
+			http.Error(w, "Error syncing file", http.StatusInternalServerError)
+			return
+		}
 
 		// Redirect back to the home page after success
 		http.Redirect(w, r, "/", http.StatusSeeOther) // Redirect to the file selector page
 	}
 }
 
+// uploadFileHandler handles multipart/form-data uploads and writes the
+// received file into filesDir so it immediately shows up in the combo box
+// on the index page.
+func uploadFileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+
+	if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+		http.Error(w, "Upload too large or unparseable", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	uploadedFile, header, err := r.FormFile("uploadFile")
+	if err != nil {
+		http.Error(w, "Missing upload file", http.StatusBadRequest)
+		return
+	}
+	defer uploadedFile.Close()
+
+	if header.Size > maxUploadSize {
+		http.Error(w, "File too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// Sniff the actual bytes rather than trusting the client-declared
+	// part header, which is trivially spoofed by non-browser clients.
+	sniffBuf := make([]byte, 512)
+	n, err := uploadedFile.Read(sniffBuf)
+	if err != nil && err != io.EOF {
+		http.Error(w, "Error reading upload", http.StatusInternalServerError)
+		return
+	}
+	contentType := http.DetectContentType(sniffBuf[:n])
+	if i := strings.IndexByte(contentType, ';'); i != -1 {
+		contentType = contentType[:i] // drop the "; charset=..." suffix DetectContentType adds for text
+	}
+	if !allowedUploadTypes[contentType] {
+		http.Error(w, fmt.Sprintf("Unsupported file type: %s", contentType), http.StatusUnsupportedMediaType)
+		return
+	}
+	if _, err := uploadedFile.Seek(0, io.SeekStart); err != nil {
+		http.Error(w, "Error reading upload", http.StatusInternalServerError)
+		return
+	}
+
+	filename, err := sanitizeFilename(header.Filename)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := os.MkdirAll(filesDir, 0755); err != nil {
+		http.Error(w, "Unable to prepare files directory", http.StatusInternalServerError)
+		return
+	}
+
+	destPath := filepath.Join(filesDir, filename)
+
+	release := fileLocks.acquire(destPath) // Serialize access to this file across handlers
+	defer release()
+
+	if r.FormValue("overwrite") != "1" {
+		if _, err := os.Stat(destPath); err == nil {
+			http.Error(w, "File already exists; retry with ?overwrite=1", http.StatusConflict)
+			return
+		}
+	}
+
+	destFile, err := os.OpenFile(destPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		http.Error(w, "Error creating file", http.StatusInternalServerError)
+		return
+	}
+	defer destFile.Close()
+
+	if _, err := io.Copy(destFile, uploadedFile); err != nil {
+		http.Error(w, "Error saving file", http.StatusInternalServerError)
+		return
+	}
+	if err := destFile.Sync(); err != nil {
+		http.Error(w, "Error syncing file", http.StatusInternalServerError)
+		return
+	}
+
+	// Redirect back to the home page after success
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// sanitizeFilename strips any directory components from the supplied name
+// and rejects anything that still looks like it's trying to escape
+// filesDir once collapsed to its base form.
+func sanitizeFilename(name string) (string, error) {
+	base := filepath.Base(filepath.Clean(name))
+	if base == "" || base == "." || base == ".." || base == string(filepath.Separator) {
+		return "", fmt.Errorf("invalid filename")
+	}
+	return base, nil
+}
+
 // getFilesFromDirectory reads the files from the specified directory
 func getFilesFromDirectory(dir string) []string {
 	files := []string{}                  // Initialize a slice to hold file names